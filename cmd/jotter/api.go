@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiError is the structured JSON error body returned by every /api/v1
+// endpoint.
+type apiError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: message, Code: code})
+}
+
+// apiAuthenticate checks Authorization: Bearer <token> (or, for a
+// password-protected token, Bearer <token>:<password>) against the
+// token named in the request path. Unlike the browser flow, the API
+// never consults cookies.
+func (s *Server) apiAuthenticate(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	value := strings.TrimPrefix(auth, prefix)
+
+	if s.hasCredential(token) {
+		parts := strings.SplitN(value, ":", 2)
+		return len(parts) == 2 && parts[0] == token && s.checkPassword(token, parts[1])
+	}
+	return value == token
+}
+
+// jotPath returns token's filename, validating its format.
+func (s *Server) jotPath(token string) (string, bool) {
+	if !tokenRe.MatchString(token) {
+		return "", false
+	}
+	return filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token)), true
+}
+
+// currentRev returns the latest known revision for token: the in-memory
+// counter maintained by recordRevision if this process has written to
+// it, falling back to the last line of jot_<token>.log, or 0 if neither
+// exists yet (e.g. a jot nobody has edited since creation).
+func (s *Server) currentRev(token string) int {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	return s.currentRevLocked(token)
+}
+
+// currentRevLocked is currentRev without acquiring historyMu, for
+// apiUpdateJot's If-Match check, which needs to hold the lock across
+// the check itself and the write/record that follows it.
+func (s *Server) currentRevLocked(token string) int {
+	if rev, ok := s.revCounter[token]; ok {
+		return rev
+	}
+
+	records, err := s.readHistory(token)
+	if err != nil || len(records) == 0 {
+		return 0
+	}
+	return records[len(records)-1].Rev
+}
+
+func (s *Server) apiAuthMiddleware(next func(w http.ResponseWriter, r *http.Request, token string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+		filename, ok := s.jotPath(token)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, "invalid_token", "invalid token format")
+			return
+		}
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "no jot with that token")
+			return
+		}
+		if !s.apiAuthenticate(r, token) {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid bearer credentials")
+			return
+		}
+
+		limiter := s.rateLimiterFor(token)
+		allowed, remaining, resetAt := limiter.take()
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limiter.capacity))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+		if !allowed {
+			writeAPIError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded for this token")
+			return
+		}
+
+		next(w, r, token)
+	}
+}
+
+// apiCreateJot handles POST /api/v1/jots.
+func (s *Server) apiCreateJot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "POST required")
+		return
+	}
+
+	var req CreateRequest
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	token, err := s.generateToken()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to generate token")
+		return
+	}
+
+	filename := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte(s.getDefaultContent(token)), 0644); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to create jot file")
+		return
+	}
+	if req.Password != "" {
+		if err := s.saveCredential(token, req.Password); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal", "failed to set password")
+			return
+		}
+	}
+
+	scheme := "http"
+	if s.tlsEnabled {
+		scheme = "https"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CreateResponse{
+		Token: token,
+		URL:   fmt.Sprintf("%s://%s:%s/%s", scheme, s.host, s.port, token),
+	})
+}
+
+// apiGetJot handles GET /api/v1/jots/{token}.
+func (s *Server) apiGetJot(w http.ResponseWriter, r *http.Request, token string) {
+	filename, _ := s.jotPath(token)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to read jot")
+		return
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to stat jot")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Content   string    `json:"content"`
+		Rev       int       `json:"rev"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}{string(content), s.currentRev(token), info.ModTime()})
+}
+
+// apiUpdateJot handles PUT /api/v1/jots/{token}, accepting a raw
+// text/plain body and an optional If-Match: <rev> header for
+// optimistic concurrency. The If-Match check, write, and revision
+// record all happen under a single historyMu hold so two concurrent
+// requests presenting the same (correct) If-Match can't both pass the
+// check and then race to write - the second one re-checks against the
+// first one's now-recorded revision before it's allowed to proceed. It
+// also syncs any live jotState via applyExternalWrite, so a token
+// that's open in a browser doesn't have its next OT write silently
+// clobber this one; that briefly nests st.mu inside historyMu (the
+// latter is process-wide, the former per-token), so a slow disk write
+// here can make an unrelated token's history request wait a beat too -
+// an acceptable tradeoff for a single-writer-per-jot tool, and
+// preferable to releasing historyMu early and risking a second request
+// for the same token running its own write+sync in between.
+func (s *Server) apiUpdateJot(w http.ResponseWriter, r *http.Request, token string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "failed to read request body")
+		return
+	}
+
+	var expected int
+	checkIfMatch := false
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		expected, err = strconv.Atoi(ifMatch)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid_if_match", "If-Match must be a revision number")
+			return
+		}
+		checkIfMatch = true
+	}
+
+	filename, _ := s.jotPath(token)
+
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	if checkIfMatch {
+		if actual := s.currentRevLocked(token); actual != expected {
+			writeAPIError(w, http.StatusPreconditionFailed, "rev_mismatch", fmt.Sprintf("If-Match %d does not match current revision %d", expected, actual))
+			return
+		}
+	}
+
+	// Keep any live jotState (handleWrite/writeJot's in-memory OT state
+	// for this token) from going stale, the same way handleRevert does -
+	// otherwise the next browser write transforms against a rev/text the
+	// API already overwrote and silently discards this write.
+	if err := s.applyExternalWrite(token, string(body), func() error {
+		return os.WriteFile(filename, body, 0644)
+	}); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to write jot")
+		return
+	}
+
+	rev, err := s.recordRevisionLocked(token, "api:"+token, body)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to record revision")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Rev int `json:"rev"`
+	}{rev})
+}
+
+// apiDeleteJot handles DELETE /api/v1/jots/{token}.
+func (s *Server) apiDeleteJot(w http.ResponseWriter, r *http.Request, token string) {
+	filename, _ := s.jotPath(token)
+	if err := os.Remove(filename); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to delete jot")
+		return
+	}
+	_ = os.Remove(s.credentialPath(token))
+	_ = os.Remove(s.logPath(token))
+	if revs, err := s.snapshotRevs(token); err == nil {
+		for _, rev := range revs {
+			_ = os.Remove(s.snapshotPath(token, rev))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiWatchJot handles GET /api/v1/jots/{token}/watch: a
+// newline-delimited JSON stream of {content} updates, functionally
+// equivalent to /updates but friendlier to `curl -N` and editor
+// plugins that would rather not parse SSE framing. It shares
+// registerClient/unregisterClient with handleUpdates so a flood of
+// watch connections is bounded by the same JOT_MAX_CLIENTS_PER_TOKEN
+// and a slow watcher gets dropped the same way a slow /updates client
+// does, rather than bypassing both protections.
+func (s *Server) apiWatchJot(w http.ResponseWriter, r *http.Request, token string) {
+	// curl -N and most NDJSON consumers never send X-Session-Id, so
+	// each connection needs its own generated id - reusing a fixed
+	// "watch:" key would collide every concurrent watcher for the same
+	// token onto one map entry, with each new connection silently
+	// stealing the channel out from under the last.
+	sessionId := r.Header.Get("X-Session-Id")
+	if sessionId == "" {
+		sessionId = "watch:" + uuid.New().String()
+	} else {
+		sessionId = "watch:" + sessionId
+	}
+
+	clientChan, slowSignal, ok := s.registerClient(token, sessionId)
+	if !ok {
+		writeAPIError(w, http.StatusTooManyRequests, "too_many_connections", "too many connections for this token")
+		return
+	}
+	defer func() {
+		s.unregisterClient(token, sessionId)
+		close(clientChan)
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-slowSignal:
+			return
+		case <-r.Context().Done():
+			return
+		case message, ok := <-clientChan:
+			if !ok {
+				return
+			}
+			// message is SSE-framed ("data: {...}\n\n"); re-render the
+			// payload as a single NDJSON line.
+			payload := strings.TrimPrefix(string(message), "data: ")
+			payload = strings.TrimSpace(payload)
+			if _, err := w.Write([]byte(payload + "\n")); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// tokenBucket is a simple per-token rate limiter: capacity tokens,
+// refilled at refillPerSec, consumed one at a time per API request.
+type tokenBucket struct {
+	mu           sync.Mutex
+	capacity     int
+	tokens       float64
+	refillPerSec float64
+	last         time.Time
+}
+
+func newTokenBucket(capacity int, refillPerSec float64) *tokenBucket {
+	return &tokenBucket{
+		capacity:     capacity,
+		tokens:       float64(capacity),
+		refillPerSec: refillPerSec,
+		last:         time.Now(),
+	}
+}
+
+// take consumes one token if available, returning whether the request
+// is allowed, the tokens remaining, and when the bucket will next have
+// a full token available.
+func (b *tokenBucket) take() (allowed bool, remaining int, resetAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = min(float64(b.capacity), b.tokens+elapsed*b.refillPerSec)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	}
+
+	remaining = int(b.tokens)
+	secondsToFull := (float64(b.capacity) - b.tokens) / b.refillPerSec
+	resetAt = now.Add(time.Duration(secondsToFull * float64(time.Second)))
+	return allowed, remaining, resetAt
+}
+
+// rateLimiterFor returns (creating if necessary) the token bucket for
+// token, sized by JOT_API_RATE_LIMIT (requests per minute, default 60).
+func (s *Server) rateLimiterFor(token string) *tokenBucket {
+	s.rateLimitersMu.Lock()
+	defer s.rateLimitersMu.Unlock()
+
+	if s.rateLimiters == nil {
+		s.rateLimiters = make(map[string]*tokenBucket)
+	}
+	if limiter, ok := s.rateLimiters[token]; ok {
+		return limiter
+	}
+
+	capacity := 60
+	if v := getEnv("JOT_API_RATE_LIMIT", ""); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			capacity = n
+		}
+	}
+	limiter := newTokenBucket(capacity, float64(capacity)/60.0)
+	s.rateLimiters[token] = limiter
+	return limiter
+}
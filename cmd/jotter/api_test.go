@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newAPITestServer(tempDir string) *Server {
+	return &Server{
+		jotDir:     tempDir,
+		clients:    make(map[string]map[string]chan []byte),
+		lastWriter: make(map[string]string),
+		shutdown:   make(chan struct{}),
+	}
+}
+
+func TestApiCreateAndGetJot(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+
+	req := httptest.NewRequest("POST", "/api/v1/jots", nil)
+	w := httptest.NewRecorder()
+	server.apiCreateJot(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	var created CreateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode create response: %v", err)
+	}
+	if created.Token == "" {
+		t.Fatal("expected a non-empty token")
+	}
+
+	getReq := httptest.NewRequest("GET", "/api/v1/jots/"+created.Token, nil)
+	getW := httptest.NewRecorder()
+	server.apiGetJot(getW, getReq, created.Token)
+
+	if getW.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", getW.Result().StatusCode)
+	}
+}
+
+func TestApiUpdateJotIfMatchMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "update-token"
+	filename := filepath.Join(tempDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte("initial"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/v1/jots/"+token, bytes.NewReader([]byte("new content")))
+	req.Header.Set("If-Match", "5")
+	w := httptest.NewRecorder()
+	server.apiUpdateJot(w, req, token)
+
+	if w.Result().StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d", w.Result().StatusCode)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "initial" {
+		t.Errorf("expected file to be untouched after a rejected If-Match, got %q", content)
+	}
+}
+
+func TestApiUpdateJotIfMatchSucceeds(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "update-token-2"
+	filename := filepath.Join(tempDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte("initial"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	if _, err := server.recordRevision(token, "seed", []byte("initial")); err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/v1/jots/"+token, bytes.NewReader([]byte("new content")))
+	req.Header.Set("If-Match", "1")
+	w := httptest.NewRecorder()
+	server.apiUpdateJot(w, req, token)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "new content" {
+		t.Errorf("expected file to be updated, got %q", content)
+	}
+}
+
+// TestApiUpdateJotConcurrentIfMatchOnlyOneWins guards against the race
+// where two PUTs presenting the same correct If-Match both pass the
+// check before either writes: apiUpdateJot now holds historyMu across
+// the check, write, and revision record, so only one of two concurrent
+// requests at rev 1 should succeed.
+func TestApiUpdateJotConcurrentIfMatchOnlyOneWins(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "race-token"
+	filename := filepath.Join(tempDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte("initial"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	if _, err := server.recordRevision(token, "seed", []byte("initial")); err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest("PUT", "/api/v1/jots/"+token, bytes.NewReader([]byte(fmt.Sprintf("writer-%d", i))))
+			req.Header.Set("If-Match", "1")
+			w := httptest.NewRecorder()
+			server.apiUpdateJot(w, req, token)
+			statuses[i] = w.Result().StatusCode
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, status := range statuses {
+		if status == http.StatusOK {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("expected exactly one writer to succeed with If-Match 1, got %d (statuses: %v)", succeeded, statuses)
+	}
+}
+
+// TestApiUpdateJotSyncsLiveJotState guards against a regression where a
+// token already tracked in memory (because a browser opened it and
+// wrote through writeJot) went stale after an API PUT: apiUpdateJot
+// must call applyExternalWrite so the next browser write transforms
+// against the API's content instead of silently reverting it.
+func TestApiUpdateJotSyncsLiveJotState(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "sync-token"
+	filename := filepath.Join(tempDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte("initial"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+
+	// Simulate a browser having opened the jot: this creates and tracks
+	// a jotState for token, just like handleWrite/writeJot would.
+	if _, _, err := server.writeJot(token, WriteRequest{Content: "initial"}); err != nil {
+		t.Fatalf("writeJot failed: %v", err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/v1/jots/"+token, bytes.NewReader([]byte("from api")))
+	w := httptest.NewRecorder()
+	server.apiUpdateJot(w, req, token)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+
+	// A subsequent browser write (full-content, as the legacy path still
+	// sends) must be based on the API's content, not revert it.
+	result, _, err := server.writeJot(token, WriteRequest{Content: "from api and then typed"})
+	if err != nil {
+		t.Fatalf("writeJot after API update failed: %v", err)
+	}
+	if result != "from api and then typed" {
+		t.Errorf("expected browser write to build on the API's content, got %q", result)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "from api" {
+		t.Errorf("expected file to still hold the API's write (writeJot only updates in-memory state), got %q", content)
+	}
+}
+
+// TestApiWatchJotGeneratesSessionIdWhenMissing guards against the
+// regression where every watcher that didn't send X-Session-Id
+// collided onto the same "watch:" map key: two concurrent watchers
+// with no header must each get their own registered client.
+func TestApiWatchJotGeneratesSessionIdWhenMissing(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "watch-token"
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	req1 := httptest.NewRequest("GET", "/api/v1/jots/"+token+"/watch", nil).WithContext(ctx1)
+	req2 := httptest.NewRequest("GET", "/api/v1/jots/"+token+"/watch", nil).WithContext(ctx2)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		server.apiWatchJot(httptest.NewRecorder(), req1, token)
+	}()
+	go func() {
+		defer wg.Done()
+		server.apiWatchJot(httptest.NewRecorder(), req2, token)
+	}()
+
+	// Let both watchers register before checking they didn't collide.
+	deadline := time.Now().Add(time.Second)
+	var count int
+	for time.Now().Before(deadline) {
+		server.mu.RLock()
+		count = len(server.clients[token])
+		server.mu.RUnlock()
+		if count == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 distinct watchers registered, got %d", count)
+	}
+
+	cancel1()
+	cancel2()
+	wg.Wait()
+}
+
+func TestApiWatchJotRespectsMaxClientsPerToken(t *testing.T) {
+	t.Setenv("JOT_MAX_CLIENTS_PER_TOKEN", "1")
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "watch-cap-token"
+
+	clientChan, _, ok := server.registerClient(token, "existing-watcher")
+	if !ok {
+		t.Fatal("failed to register the first watcher")
+	}
+	defer close(clientChan)
+
+	req := httptest.NewRequest("GET", "/api/v1/jots/"+token+"/watch", nil)
+	w := httptest.NewRecorder()
+	server.apiWatchJot(w, req, token)
+
+	if w.Result().StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the per-token cap is reached, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestTokenBucketTake(t *testing.T) {
+	bucket := newTokenBucket(2, 1)
+
+	allowed, remaining, _ := bucket.take()
+	if !allowed || remaining != 1 {
+		t.Fatalf("expected first take to succeed with 1 remaining, got allowed=%v remaining=%d", allowed, remaining)
+	}
+
+	allowed, remaining, _ = bucket.take()
+	if !allowed || remaining != 0 {
+		t.Fatalf("expected second take to succeed with 0 remaining, got allowed=%v remaining=%d", allowed, remaining)
+	}
+
+	if allowed, _, _ := bucket.take(); allowed {
+		t.Error("expected the third take to be rate-limited")
+	}
+}
+
+func TestCurrentRevFallsBackToHistoryLog(t *testing.T) {
+	tempDir := t.TempDir()
+	server := newAPITestServer(tempDir)
+	token := "fallback-token"
+
+	if rev := server.currentRev(token); rev != 0 {
+		t.Errorf("expected rev 0 for an untouched token, got %d", rev)
+	}
+
+	if _, err := server.recordRevision(token, "writer", []byte("hello")); err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+
+	// Simulate a restart by clearing the in-memory counter: currentRev
+	// should still find the revision by reading the log from disk.
+	server.historyMu.Lock()
+	delete(server.revCounter, token)
+	server.historyMu.Unlock()
+
+	if rev := server.currentRev(token); rev != 1 {
+		t.Errorf("expected currentRev to fall back to the history log and find rev 1, got %d", rev)
+	}
+}
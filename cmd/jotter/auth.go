@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionCookieName is the cookie that carries a signed, short-lived proof
+// that the bearer authenticated against a password-protected jot.
+const sessionCookieName = "jot_session"
+
+// sessionTTL controls how long a successful /login stays valid.
+const sessionTTL = 24 * time.Hour
+
+// credential is the on-disk record for a password-protected jot, stored
+// alongside the jot text as jot_<token>.meta.
+type credential struct {
+	PasswordHash string    `json:"passwordHash"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// CreateRequest is the JSON body accepted by POST /create.
+type CreateRequest struct {
+	Password string `json:"password"`
+}
+
+// CreateResponse is returned by POST /create.
+type CreateResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// LoginRequest is the JSON body accepted by POST /login.
+type LoginRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (s *Server) credentialPath(token string) string {
+	return filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.meta", token))
+}
+
+// hasCredential reports whether token is password-protected.
+func (s *Server) hasCredential(token string) bool {
+	_, err := os.Stat(s.credentialPath(token))
+	return err == nil
+}
+
+func (s *Server) loadCredential(token string) (*credential, error) {
+	data, err := os.ReadFile(s.credentialPath(token))
+	if err != nil {
+		return nil, err
+	}
+	var cred credential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, fmt.Errorf("failed to parse credential: %w", err)
+	}
+	return &cred, nil
+}
+
+// saveCredential hashes password and writes it to jot_<token>.meta.
+func (s *Server) saveCredential(token, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	cred := credential{
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %w", err)
+	}
+	return os.WriteFile(s.credentialPath(token), data, 0600)
+}
+
+// checkPassword reports whether password matches the stored hash for token.
+func (s *Server) checkPassword(token, password string) bool {
+	cred, err := s.loadCredential(token)
+	if err != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(cred.PasswordHash), []byte(password)) == nil
+}
+
+// signSessionValue signs "token:expiry" with the server's session secret.
+func (s *Server) signSessionValue(token string, expiry int64) string {
+	payload := fmt.Sprintf("%s:%d", token, expiry)
+	mac := hmac.New(sha256.New, s.sessionSecret)
+	mac.Write([]byte(payload))
+	sig := mac.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// newSessionCookie builds a signed session cookie proving successful
+// authentication against token.
+func (s *Server) newSessionCookie(token string) *http.Cookie {
+	expiry := time.Now().Add(sessionTTL).Unix()
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    s.signSessionValue(token, expiry),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// verifySession checks the signed session cookie against token.
+func (s *Server) verifySession(r *http.Request, token string) bool {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return false
+	}
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.sessionSecret)
+	mac.Write(payloadBytes)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return false
+	}
+
+	payload := string(payloadBytes)
+	sep := strings.LastIndex(payload, ":")
+	if sep < 0 {
+		return false
+	}
+	payloadToken, expiryStr := payload[:sep], payload[sep+1:]
+	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil {
+		return false
+	}
+	if time.Now().Unix() > expiry {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(payloadToken), []byte(token)) == 1
+}
+
+// authenticateProtected checks the Authorization: Bearer <token>:<password>
+// header or a valid session cookie for a password-protected token.
+func (s *Server) authenticateProtected(r *http.Request, token string) bool {
+	if s.verifySession(r, token) {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	creds := strings.SplitN(strings.TrimPrefix(auth, prefix), ":", 2)
+	if len(creds) != 2 || creds[0] != token {
+		return false
+	}
+	return s.checkPassword(token, creds[1])
+}
+
+// handleCreate creates a new jot, optionally password-protected, and
+// returns its token as JSON. This is the scripting-friendly counterpart
+// to the cookie-based /new flow.
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateRequest
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	token, err := s.generateToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	filename := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte(s.getDefaultContent(token)), 0644); err != nil {
+		http.Error(w, "Failed to create jot file", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Password != "" {
+		if err := s.saveCredential(token, req.Password); err != nil {
+			http.Error(w, "Failed to set password", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	scheme := "http"
+	if s.tlsEnabled {
+		scheme = "https"
+	}
+	resp := CreateResponse{
+		Token: token,
+		URL:   fmt.Sprintf("%s://%s:%s/%s", scheme, s.host, s.port, token),
+	}
+
+	if req.Password != "" {
+		http.SetCookie(w, s.newSessionCookie(token))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleLogin verifies a token+password pair and, on success, sets the
+// signed session cookie used by getValidToken for subsequent requests.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !tokenRe.MatchString(req.Token) || !s.hasCredential(req.Token) || !s.checkPassword(req.Token, req.Password) {
+		http.Error(w, "Invalid token or password", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, s.newSessionCookie(req.Token))
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    req.Token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLogout clears the session cookie.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func generateSessionSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate session secret: %w", err)
+	}
+	return secret, nil
+}
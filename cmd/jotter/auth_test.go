@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAuthTestServer(t *testing.T) *Server {
+	t.Helper()
+	secret, err := generateSessionSecret()
+	if err != nil {
+		t.Fatalf("generateSessionSecret failed: %v", err)
+	}
+	return &Server{
+		jotDir:        t.TempDir(),
+		host:          "localhost",
+		port:          "8000",
+		sessionSecret: secret,
+	}
+}
+
+func TestSaveAndCheckPassword(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "pw-token"
+
+	if server.hasCredential(token) {
+		t.Fatal("expected no credential before saveCredential is called")
+	}
+
+	if err := server.saveCredential(token, "correct-horse"); err != nil {
+		t.Fatalf("saveCredential failed: %v", err)
+	}
+	if !server.hasCredential(token) {
+		t.Fatal("expected hasCredential to report true after saving")
+	}
+
+	if !server.checkPassword(token, "correct-horse") {
+		t.Error("expected the correct password to check out")
+	}
+	if server.checkPassword(token, "wrong-password") {
+		t.Error("expected an incorrect password to be rejected")
+	}
+}
+
+func TestSignAndVerifySession(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "session-token"
+
+	cookie := server.newSessionCookie(token)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if !server.verifySession(req, token) {
+		t.Error("expected a freshly-signed session cookie to verify")
+	}
+	if server.verifySession(req, "some-other-token") {
+		t.Error("expected the session to only verify for the token it was signed for")
+	}
+}
+
+func TestVerifySessionRejectsTamperedCookie(t *testing.T) {
+	server := newAuthTestServer(t)
+	cookie := server.newSessionCookie("session-token")
+
+	tampered := *cookie
+	tampered.Value = cookie.Value + "x"
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&tampered)
+	if server.verifySession(req, "session-token") {
+		t.Error("expected a tampered session cookie to fail verification")
+	}
+}
+
+func TestVerifySessionRejectsExpired(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "expiring-token"
+
+	value := server.signSessionValue(token, time.Now().Add(-time.Minute).Unix())
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: value})
+
+	if server.verifySession(req, token) {
+		t.Error("expected an expired session to fail verification")
+	}
+}
+
+func TestVerifySessionRejectsWrongSecret(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "session-token"
+	cookie := server.newSessionCookie(token)
+
+	otherServer := newAuthTestServer(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if otherServer.verifySession(req, token) {
+		t.Error("expected a session signed by a different server's secret to fail verification")
+	}
+}
+
+func TestAuthenticateProtected(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "protected-token"
+	if err := server.saveCredential(token, "hunter2"); err != nil {
+		t.Fatalf("saveCredential failed: %v", err)
+	}
+
+	bearerReq := httptest.NewRequest("GET", "/", nil)
+	bearerReq.Header.Set("Authorization", "Bearer "+token+":hunter2")
+	if !server.authenticateProtected(bearerReq, token) {
+		t.Error("expected a correct Bearer token:password to authenticate")
+	}
+
+	badBearerReq := httptest.NewRequest("GET", "/", nil)
+	badBearerReq.Header.Set("Authorization", "Bearer "+token+":wrong")
+	if server.authenticateProtected(badBearerReq, token) {
+		t.Error("expected an incorrect password to be rejected")
+	}
+
+	sessionReq := httptest.NewRequest("GET", "/", nil)
+	sessionReq.AddCookie(server.newSessionCookie(token))
+	if !server.authenticateProtected(sessionReq, token) {
+		t.Error("expected a valid session cookie to authenticate")
+	}
+}
+
+func TestHandleCreateWithPassword(t *testing.T) {
+	server := newAuthTestServer(t)
+
+	body, _ := json.Marshal(CreateRequest{Password: "hunter2"})
+	req := httptest.NewRequest("POST", "/create", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	server.handleCreate(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var created CreateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode create response: %v", err)
+	}
+	if !server.hasCredential(created.Token) {
+		t.Error("expected a credential to be saved for the new token")
+	}
+
+	found := false
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookieName {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected a session cookie to be set when creating a password-protected jot")
+	}
+}
+
+func TestHandleLogin(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "login-token"
+	if err := server.saveCredential(token, "hunter2"); err != nil {
+		t.Fatalf("saveCredential failed: %v", err)
+	}
+
+	body, _ := json.Marshal(LoginRequest{Token: token, Password: "hunter2"})
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	server.handleLogin(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", resp.StatusCode)
+	}
+
+	var sawSession, sawToken bool
+	for _, cookie := range resp.Cookies() {
+		switch cookie.Name {
+		case sessionCookieName:
+			sawSession = true
+		case "token":
+			sawToken = true
+			if cookie.Value != token {
+				t.Errorf("expected token cookie value %q, got %q", token, cookie.Value)
+			}
+		}
+	}
+	if !sawSession || !sawToken {
+		t.Error("expected both a session cookie and a token cookie to be set on successful login")
+	}
+}
+
+func TestHandleLoginRejectsWrongPassword(t *testing.T) {
+	server := newAuthTestServer(t)
+	token := "login-token-2"
+	if err := server.saveCredential(token, "hunter2"); err != nil {
+		t.Fatalf("saveCredential failed: %v", err)
+	}
+
+	body, _ := json.Marshal(LoginRequest{Token: token, Password: "wrong"})
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	server.handleLogin(w, req)
+
+	if w.Result().StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestHandleLogout(t *testing.T) {
+	server := newAuthTestServer(t)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	w := httptest.NewRecorder()
+
+	server.handleLogout(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", resp.StatusCode)
+	}
+
+	found := false
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookieName {
+			found = true
+			if cookie.MaxAge >= 0 {
+				t.Error("expected logout to expire the session cookie with a negative MaxAge")
+			}
+		}
+	}
+	if !found {
+		t.Error("expected handleLogout to set a clearing session cookie")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsStreamingPath(t *testing.T) {
+	cases := map[string]bool{
+		"/updates":                  true,
+		"/api/v1/jots/abc123/watch": true,
+		"/":                         false,
+		"/write":                    false,
+		"/history":                  false,
+		"/api/v1/jots/abc123":       false,
+	}
+	for path, want := range cases {
+		if got := isStreamingPath(path); got != want {
+			t.Errorf("isStreamingPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestNoTransform(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if noTransform(req) {
+		t.Error("expected noTransform to be false with no Cache-Control header")
+	}
+
+	req.Header.Set("Cache-Control", "no-transform")
+	if !noTransform(req) {
+		t.Error("expected noTransform to be true with Cache-Control: no-transform")
+	}
+
+	req.Header.Set("Cache-Control", "max-age=0, no-transform")
+	if !noTransform(req) {
+		t.Error("expected noTransform to be true among multiple Cache-Control directives")
+	}
+}
+
+// TestCompressionMiddlewareBypassesStreamingPaths guards against the
+// regression where /updates was wrapped in the generic buffering
+// compressor and its small SSE frames never got flushed to the client.
+func TestCompressionMiddlewareBypassesStreamingPaths(t *testing.T) {
+	var sawRequest bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawRequest = true
+		// A real handler would keep writing/flushing small frames over
+		// time; here we just confirm the request reaches it unwrapped.
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("expected the streaming path's ResponseWriter to support http.Flusher")
+		}
+		w.Write([]byte("data: {}\n\n"))
+	})
+
+	handler, err := compressionMiddleware(inner)
+	if err != nil {
+		t.Fatalf("compressionMiddleware failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/updates", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if !sawRequest {
+		t.Fatal("expected the wrapped handler to be invoked")
+	}
+	if enc := w.Result().Header.Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected /updates to bypass compression, got Content-Encoding: %s", enc)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+// snapshotEveryNWrites controls how often a full-content snapshot is
+// taken, in addition to the time-based trigger below.
+const snapshotEveryNWrites = 20
+
+// snapshotEveryDuration is the time-based snapshot trigger: if this much
+// time has passed since the last snapshot, the next write snapshots too.
+const snapshotEveryDuration = 5 * time.Minute
+
+// historyRecord is one line of jot_<token>.log: a compact, append-only
+// audit trail of writes. It intentionally does not carry the write's
+// content — only a snapshot (see recordRevision) does that — so the log
+// stays cheap to read in full for GET /history.
+type historyRecord struct {
+	Rev       int       `json:"rev"`
+	Ts        time.Time `json:"ts"`
+	SessionId string    `json:"sessionId"`
+	SHA256    string    `json:"sha256"`
+	SizeDelta int       `json:"sizeDelta"`
+}
+
+func (s *Server) logPath(token string) string {
+	return filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.log", token))
+}
+
+func (s *Server) snapshotPath(token string, rev int) string {
+	return filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.snap.%d", token, rev))
+}
+
+// recordRevision appends a historyRecord for content and, every
+// snapshotEveryNWrites writes or snapshotEveryDuration (whichever comes
+// first), persists a full-content snapshot so GET /revision has
+// something to reconstruct from. It returns the new revision number.
+func (s *Server) recordRevision(token, sessionId string, content []byte) (int, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	return s.recordRevisionLocked(token, sessionId, content)
+}
+
+// recordRevisionLocked is recordRevision without acquiring historyMu,
+// for callers (apiUpdateJot's If-Match check) that already hold it
+// across a larger check-then-act section.
+func (s *Server) recordRevisionLocked(token, sessionId string, content []byte) (int, error) {
+	if s.revCounter == nil {
+		s.revCounter = make(map[string]int)
+		s.lastSize = make(map[string]int)
+		s.lastSnapshot = make(map[string]time.Time)
+	}
+
+	rev := s.revCounter[token] + 1
+	s.revCounter[token] = rev
+
+	prevSize := s.lastSize[token]
+	s.lastSize[token] = len(content)
+
+	sum := sha256.Sum256(content)
+	record := historyRecord{
+		Rev:       rev,
+		Ts:        time.Now(),
+		SessionId: sessionId,
+		SHA256:    hex.EncodeToString(sum[:]),
+		SizeDelta: len(content) - prevSize,
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal history record: %w", err)
+	}
+
+	f, err := os.OpenFile(s.logPath(token), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open history log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return 0, fmt.Errorf("failed to append history log: %w", err)
+	}
+
+	due := rev%snapshotEveryNWrites == 0 || time.Since(s.lastSnapshot[token]) > snapshotEveryDuration
+	if rev == 1 || due {
+		if err := os.WriteFile(s.snapshotPath(token, rev), content, 0644); err != nil {
+			return 0, fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		s.lastSnapshot[token] = time.Now()
+	}
+
+	return rev, nil
+}
+
+// readHistory returns every historyRecord logged for token, oldest first.
+func (s *Server) readHistory(token string) ([]historyRecord, error) {
+	f, err := os.Open(s.logPath(token))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []historyRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record historyRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to parse history log: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// snapshotRevs returns the revisions that have a full-content snapshot
+// on disk for token, sorted ascending.
+func (s *Server) snapshotRevs(token string) ([]int, error) {
+	pattern := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.snap.*", token))
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("jot_%s.snap.", token)
+	var revs []int
+	for _, m := range matches {
+		base := filepath.Base(m)
+		revStr := strings.TrimPrefix(base, prefix)
+		rev, err := strconv.Atoi(revStr)
+		if err != nil {
+			continue
+		}
+		revs = append(revs, rev)
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+// reconstructRevision returns the best-effort content at rev: the
+// nearest snapshot at or before rev. This design only retains full
+// content at snapshot points (see recordRevision), not per-write diffs,
+// so a revision that falls between two snapshots is approximated by the
+// snapshot immediately preceding it rather than byte-exact replay.
+func (s *Server) reconstructRevision(token string, rev int) (content []byte, servedRev int, err error) {
+	revs, err := s.snapshotRevs(token)
+	if err != nil {
+		return nil, 0, err
+	}
+	servedRev = 0
+	for _, r := range revs {
+		if r <= rev {
+			servedRev = r
+		}
+	}
+	if servedRev == 0 {
+		return nil, 0, fmt.Errorf("no snapshot available at or before revision %d", rev)
+	}
+	content, err = os.ReadFile(s.snapshotPath(token, servedRev))
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, servedRev, nil
+}
+
+// shouldKeepSnapshot implements the logarithmic retention backoff used by
+// `jotter compact`: the 10 most recent snapshots are always kept, then
+// every 10th back to 100 revisions old, every 100th back to 1000, and so
+// on, so storage grows with log(history length) rather than linearly.
+func shouldKeepSnapshot(rev, maxRev int) bool {
+	distance := maxRev - rev
+	if distance < 10 {
+		return true
+	}
+	step := 10
+	for threshold := 100; distance >= threshold; threshold *= 10 {
+		step *= 10
+	}
+	return rev%step == 0
+}
+
+// runCompact implements `jotter compact`: it prunes old snapshot files
+// for every jot in JOT_DIR according to shouldKeepSnapshot. The
+// historyRecord log itself is never pruned, since it's just a cheap
+// append-only audit trail.
+func runCompact() error {
+	jotDir := getEnv("JOT_DIR", "jots")
+
+	matches, err := filepath.Glob(filepath.Join(jotDir, "jot_*.txt"))
+	if err != nil {
+		return fmt.Errorf("failed to list jots: %w", err)
+	}
+
+	s := &Server{jotDir: jotDir}
+	for _, m := range matches {
+		base := filepath.Base(m)
+		token := strings.TrimPrefix(strings.TrimSuffix(base, ".txt"), "jot_")
+
+		revs, err := s.snapshotRevs(token)
+		if err != nil {
+			return fmt.Errorf("failed to list snapshots for %s: %w", token, err)
+		}
+		if len(revs) == 0 {
+			continue
+		}
+		maxRev := revs[len(revs)-1]
+
+		for _, rev := range revs {
+			if shouldKeepSnapshot(rev, maxRev) {
+				continue
+			}
+			if err := os.Remove(s.snapshotPath(token, rev)); err != nil {
+				return fmt.Errorf("failed to prune snapshot %s@%d: %w", token, rev, err)
+			}
+			fmt.Printf("pruned %s@%d\n", token, rev)
+		}
+	}
+	return nil
+}
+
+// renderHistoryList renders the #history-list panel content: one
+// clickable entry per record, newest first, each wired to preview that
+// revision via GET /revision. token is assumed already validated by
+// tokenRe, so it's safe to interpolate directly into the markup.
+func renderHistoryList(token string, records []historyRecord) string {
+	var b strings.Builder
+	b.WriteString(`<div id="history-list">`)
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		fmt.Fprintf(&b,
+			`<div class="history-entry" data-on-click="@get('/revision?token=%s&rev=%d')">rev %d &mdash; %s (%+d bytes)</div>`,
+			token, rec.Rev, rec.Rev, rec.Ts.Format(time.RFC3339), rec.SizeDelta,
+		)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// handleHistory serves GET /history?token=..., patching #history-list
+// with a clickable entry per revision via datastar-patch-elements.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	token, err := s.getValidToken(r)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	records, err := s.readHistory(token)
+	if err != nil {
+		http.Error(w, "Failed to read history", http.StatusInternalServerError)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.PatchElements(renderHistoryList(token, records), datastar.WithSelectorID("history-list")); err != nil {
+		log.Printf("Error patching history list for %s: %v", token, err)
+	}
+}
+
+// handleRevision serves GET /revision?token=...&rev=..., patching the
+// textarea's bound content signal to preview the requested revision's
+// text and setting $previewRev so the Revert button appears, without
+// touching the live jot file until Revert is actually pressed.
+func (s *Server) handleRevision(w http.ResponseWriter, r *http.Request) {
+	token, err := s.getValidToken(r)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	rev, err := strconv.Atoi(r.URL.Query().Get("rev"))
+	if err != nil {
+		http.Error(w, "Invalid rev", http.StatusBadRequest)
+		return
+	}
+
+	content, servedRev, err := s.reconstructRevision(token, rev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.MarshalAndPatchSignals(map[string]any{
+		"content":    string(content),
+		"previewRev": servedRev,
+	}); err != nil {
+		log.Printf("Error patching preview signals for %s: %v", token, err)
+	}
+}
+
+// handleRevert serves POST /revert?token=...&rev=..., atomically
+// restoring the jot to the (possibly approximated) content at rev,
+// recording the restore itself as a new revision, and patching
+// $previewRev back to null (hiding the Revert button again) now that
+// the preview is the live content.
+func (s *Server) handleRevert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := s.getValidToken(r)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	rev, err := strconv.Atoi(r.URL.Query().Get("rev"))
+	if err != nil {
+		http.Error(w, "Invalid rev", http.StatusBadRequest)
+		return
+	}
+
+	content, servedRev, err := s.reconstructRevision(token, rev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	filename := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token))
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		http.Error(w, "Failed to write revert file", http.StatusInternalServerError)
+		return
+	}
+
+	// The rename and the jotState sync happen under the same st.mu hold
+	// (if a jotState is tracked), so handleFileChange's locked
+	// content+rev read can't observe this revert's new file content
+	// paired with the pre-revert rev, or vice versa.
+	if err := s.applyExternalWrite(token, string(content), func() error {
+		return os.Rename(tmp, filename)
+	}); err != nil {
+		http.Error(w, "Failed to apply revert", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := s.recordRevision(token, "revert", content); err != nil {
+		log.Printf("Error recording revert as revision: %v", err)
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.MarshalAndPatchSignals(map[string]any{
+		"content":      string(content),
+		"previewRev":   nil,
+		"revertedFrom": servedRev,
+	}); err != nil {
+		log.Printf("Error patching signals after revert for %s: %v", token, err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordRevisionAndReconstruct(t *testing.T) {
+	tempDir := t.TempDir()
+	server := &Server{jotDir: tempDir}
+	token := "history-token"
+
+	rev1, err := server.recordRevision(token, "session-a", []byte("hello"))
+	if err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+	if rev1 != 1 {
+		t.Fatalf("expected rev 1, got %d", rev1)
+	}
+
+	rev2, err := server.recordRevision(token, "session-b", []byte("hello world"))
+	if err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+	if rev2 != 2 {
+		t.Fatalf("expected rev 2, got %d", rev2)
+	}
+
+	records, err := server.readHistory(token)
+	if err != nil {
+		t.Fatalf("readHistory failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 history records, got %d", len(records))
+	}
+	if records[1].SizeDelta != len("hello world")-len("hello") {
+		t.Errorf("unexpected sizeDelta: %d", records[1].SizeDelta)
+	}
+
+	// rev 1 is always snapshotted (first write), so it should reconstruct exactly.
+	content, servedRev, err := server.reconstructRevision(token, 1)
+	if err != nil {
+		t.Fatalf("reconstructRevision failed: %v", err)
+	}
+	if servedRev != 1 || string(content) != "hello" {
+		t.Errorf("expected rev 1 'hello', got rev %d %q", servedRev, content)
+	}
+}
+
+func TestReconstructRevisionNoSnapshot(t *testing.T) {
+	tempDir := t.TempDir()
+	server := &Server{jotDir: tempDir}
+	if _, _, err := server.reconstructRevision("missing-token", 1); err == nil {
+		t.Error("expected an error when no snapshot exists")
+	}
+}
+
+func TestShouldKeepSnapshot(t *testing.T) {
+	if !shouldKeepSnapshot(95, 100) {
+		t.Error("expected recent revisions (within 10) to always be kept")
+	}
+	if shouldKeepSnapshot(55, 100) {
+		t.Error("expected a non-multiple-of-10 revision beyond the recent window to be pruned")
+	}
+	if !shouldKeepSnapshot(50, 100) {
+		t.Error("expected a multiple-of-10 revision to be kept in the 10-100 window")
+	}
+}
+
+func TestRenderHistoryList(t *testing.T) {
+	records := []historyRecord{
+		{Rev: 1, SHA256: "aaa", SizeDelta: 5},
+		{Rev: 2, SHA256: "bbb", SizeDelta: -2},
+	}
+	html := renderHistoryList("tok123", records)
+
+	if !strings.Contains(html, `id="history-list"`) {
+		t.Error("expected the rendered list to carry the history-list id for datastar's selector")
+	}
+	if !strings.Contains(html, "/revision?token=tok123&rev=2") {
+		t.Error("expected an entry linking to rev 2")
+	}
+	// Newest first.
+	if strings.Index(html, "rev 2") > strings.Index(html, "rev 1") {
+		t.Error("expected newest revision to be listed first")
+	}
+}
+
+// TestApplyExternalWriteSyncsJotState guards against a regression where
+// handleRevert/apiUpdateJot's jotState sync (applyExternalWrite) stopped
+// keeping an already-tracked jotState in sync with content written
+// directly to disk.
+func TestApplyExternalWriteSyncsJotState(t *testing.T) {
+	tempDir := t.TempDir()
+	token := "revert-sync-token"
+	filename := filepath.Join(tempDir, "jot_"+token+".txt")
+	if err := os.WriteFile(filename, []byte("original"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	server := &Server{jotDir: tempDir}
+
+	if _, err := server.recordRevision(token, "author", []byte("original")); err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+
+	// Touch the jotState so a tracked state exists to keep in sync.
+	if _, err := server.getJotState(token); err != nil {
+		t.Fatalf("getJotState failed: %v", err)
+	}
+
+	wrote := false
+	if err := server.applyExternalWrite(token, "restored content", func() error {
+		wrote = true
+		return nil
+	}); err != nil {
+		t.Fatalf("applyExternalWrite failed: %v", err)
+	}
+	if !wrote {
+		t.Error("expected applyExternalWrite to call write")
+	}
+
+	st, ok := server.peekJotState(token)
+	if !ok {
+		t.Fatal("expected a tracked jotState")
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.text != "restored content" {
+		t.Errorf("expected jotState text to reflect the external write, got %q", st.text)
+	}
+}
+
+// TestApplyExternalWriteSkipsSyncOnWriteFailure guards against syncing
+// jotState to content that was never actually written to disk.
+func TestApplyExternalWriteSkipsSyncOnWriteFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	token := "revert-fail-token"
+	filename := filepath.Join(tempDir, "jot_"+token+".txt")
+	if err := os.WriteFile(filename, []byte("original"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	server := &Server{jotDir: tempDir}
+
+	if _, err := server.getJotState(token); err != nil {
+		t.Fatalf("getJotState failed: %v", err)
+	}
+
+	writeErr := fmt.Errorf("disk full")
+	if err := server.applyExternalWrite(token, "should not apply", func() error {
+		return writeErr
+	}); err != writeErr {
+		t.Fatalf("expected applyExternalWrite to surface the write error, got %v", err)
+	}
+
+	st, ok := server.peekJotState(token)
+	if !ok {
+		t.Fatal("expected a tracked jotState")
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.text != "original" {
+		t.Errorf("expected jotState to be untouched after a failed write, got %q", st.text)
+	}
+}
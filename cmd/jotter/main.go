@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -35,19 +36,182 @@ const htmlTemplate = `<!doctype html>
         <link rel="stylesheet" href="/static/css/style.css">
         <script type="module" src="/static/js/datastar.js"></script>
     </head>
-    <body data-on-load="@get('/updates')">
+    <body data-signals="{previewRev: null}">
         <textarea
             id="jot-field"
-            placeholder="Start typing..."
             data-bind-content
-            data-on-input__debounce.500ms="@post('/write')"
+            placeholder="Start typing..."
         >{{.Content}}</textarea>
+        <details id="history-panel">
+            <summary data-on-click="@get('/history?token={{.Token}}')">History</summary>
+            <div id="history-list"></div>
+            <button
+                id="revert-button"
+                data-show="$previewRev !== null"
+                data-on-click="@post('/revert?token={{.Token}}&rev='+$previewRev)"
+            >Revert to this revision</button>
+        </details>
+        <script>
+            // Sends real {baseRev, ops} deltas instead of the full
+            // textarea on every debounced input, so two tabs editing
+            // concurrently merge via the server's OT transform
+            // (writeJot) instead of whichever POST lands last winning.
+            // This opens its own /updates connection rather than going
+            // through datastar's data-on-load/data-bind-content for live
+            // remote writes, so an incoming remote write resyncs
+            // lastText and rev in the same step, atomically - otherwise
+            // a remote patch would update the visible text without
+            // updating the baseline this tab diffs against, and the
+            // next local edit's op would double up whatever the remote
+            // write inserted. diff() walks text by Unicode code point
+            // (Array.from), matching the []rune indexing
+            // applyOps/transformOp use server-side, so ops land at the
+            // same offsets on both ends even across astral characters.
+            //
+            // data-bind-content is still on the textarea, but only for
+            // /history's preview and /revert's one-shot
+            // MarshalAndPatchSignals responses (history.go) - those are
+            // user-initiated @get/@post calls, not a recurring stream,
+            // so they can't re-trigger the double-insert race the
+            // /updates rewrite above avoids. A revert does leave this
+            // tab's own lastText/rev stale until the next broadcast (or
+            // its own next edit 409s and falls back to a full-content
+            // write); the live text shown is still correct either way.
+            (() => {
+                const field = document.getElementById('jot-field');
+                const sessionId = crypto.randomUUID();
+                let lastText = field.value;
+                let rev = {{.Rev}};
+                let timer = null;
+
+                function diff(oldText, newText) {
+                    const oldChars = Array.from(oldText);
+                    const newChars = Array.from(newText);
+                    const maxPrefix = Math.min(oldChars.length, newChars.length);
+                    let prefix = 0;
+                    while (prefix < maxPrefix && oldChars[prefix] === newChars[prefix]) {
+                        prefix++;
+                    }
+                    const maxSuffix = Math.min(oldChars.length, newChars.length) - prefix;
+                    let suffix = 0;
+                    while (
+                        suffix < maxSuffix &&
+                        oldChars[oldChars.length - 1 - suffix] === newChars[newChars.length - 1 - suffix]
+                    ) {
+                        suffix++;
+                    }
+                    return {
+                        retain: prefix,
+                        delete: oldChars.length - prefix - suffix,
+                        insert: newChars.slice(prefix, newChars.length - suffix).join(''),
+                    };
+                }
+
+                function post(body) {
+                    return fetch('/write', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json', 'X-Session-Id': sessionId },
+                        body: JSON.stringify(body),
+                    });
+                }
+
+                async function flush() {
+                    const newText = field.value;
+                    if (newText === lastText) {
+                        return;
+                    }
+                    let res = await post({ baseRev: rev, ops: [diff(lastText, newText)] });
+                    if (res.status === 409) {
+                        res = await post({ content: newText });
+                    }
+                    // Only advance the baseline on an actual success -
+                    // on any other failure (network error, 500, etc.)
+                    // the server never applied this edit, so lastText/
+                    // rev must stay put or the next flush would diff
+                    // from a baseline the server doesn't share.
+                    if (!res.ok) {
+                        return;
+                    }
+                    lastText = newText;
+                    const nextRev = parseInt(res.headers.get('X-Jot-Rev'), 10);
+                    if (!Number.isNaN(nextRev)) {
+                        rev = nextRev;
+                    }
+                }
+
+                // Chained onto pending, not called directly: a flush in
+                // flight (slow network) leaves lastText/rev unsynced
+                // until its POST resolves, and the next debounce timer
+                // firing before then would otherwise read that same
+                // stale baseline out from under it. Chaining serializes
+                // flushes so each one always diffs from the state the
+                // previous one actually landed.
+                // .catch, not just .then: a rejected promise short-
+                // circuits every later .then in the chain, so one
+                // network failure would otherwise silently stop every
+                // flush for the rest of the page's life.
+                let pending = Promise.resolve();
+                field.addEventListener('input', () => {
+                    clearTimeout(timer);
+                    timer = setTimeout(() => {
+                        pending = pending.then(flush).catch(() => {});
+                    }, 500);
+                });
+
+                const updates = new EventSource('/updates?sessionId=' + sessionId);
+                updates.onmessage = (event) => {
+                    const msg = JSON.parse(event.data);
+                    const patch = msg['datastar-patch-signals'];
+                    if (!patch || typeof patch.content !== 'string') {
+                        return;
+                    }
+                    lastText = patch.content;
+                    field.value = patch.content;
+                    if (typeof patch.rev === 'number') {
+                        rev = patch.rev;
+                    }
+                };
+            })();
+        </script>
+    </body>
+</html>`
+
+const loginTemplate = `<!doctype html>
+<html lang="en">
+    <head>
+        <meta charset="UTF-8" />
+        <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+        <title>jotter</title>
+        <link rel="icon" type="image/x-icon" href="/static/img/favicon.ico" />
+        <link rel="stylesheet" href="/static/css/style.css">
+    </head>
+    <body>
+        <form method="post" action="/login" data-on-submit="@post('/login')">
+            <p>This jot is password-protected.</p>
+            <input type="hidden" name="token" value="{{.Token}}" />
+            <input type="password" name="password" placeholder="Password" autofocus />
+            <button type="submit">Unlock</button>
+        </form>
     </body>
 </html>`
 
 // tokenRe validates the format of a token to prevent path traversal.
 var tokenRe = regexp.MustCompile(`^[A-Za-z0-9_-]+=*$`)
 
+// errUnauthorized is returned by getValidToken when a token is
+// password-protected and the request carries no valid credentials.
+var errUnauthorized = fmt.Errorf("unauthorized")
+
+// writeTokenError replies with 401 for errUnauthorized and 400 for any
+// other getValidToken failure.
+func writeTokenError(w http.ResponseWriter, err error) {
+	if err == errUnauthorized {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
 type Server struct {
 	jotDir     string
 	host       string
@@ -60,11 +224,60 @@ type Server struct {
 	clients    map[string]map[string]chan []byte // token -> sessionId -> channel
 	mu         sync.RWMutex
 	tmpl       *template.Template
+	loginTmpl  *template.Template
+
+	// stuckSince tracks, per token and sessionId, when a client's
+	// channel was first observed full, so broadcastToClients can drop
+	// it after slowClientTimeout instead of leaking a permanently
+	// desynced consumer. slowClients holds the signal channel used to
+	// tell that session's handleUpdates goroutine to end the
+	// connection once dropped. Both are guarded by mu.
+	stuckSince  map[string]map[string]time.Time
+	slowClients map[string]map[string]chan struct{}
+
+	// shutdown is closed by main() before httpServer.Shutdown, so every
+	// in-flight handleUpdates goroutine can send a final SSE frame and
+	// return instead of blocking until the shutdown timeout fires.
+	shutdown chan struct{}
+
+	// lastWriter records, per token, the sessionId of the client that
+	// most recently wrote the file, so the file-watcher broadcast can
+	// skip echoing the change back to its own author.
+	lastWriter map[string]string
+
+	// sessionSecret signs the session cookie issued by handleLogin for
+	// password-protected jots.
+	sessionSecret []byte
+
+	// History subsystem state: revCounter/lastSize/lastSnapshot are
+	// per-token bookkeeping for recordRevision, guarded by historyMu.
+	historyMu    sync.Mutex
+	revCounter   map[string]int
+	lastSize     map[string]int
+	lastSnapshot map[string]time.Time
+
+	// rateLimiters holds one token bucket per token for the /api/v1
+	// surface, guarded by rateLimitersMu.
+	rateLimitersMu sync.Mutex
+	rateLimiters   map[string]*tokenBucket
+
+	// jots holds the live, in-memory collaboration state (current
+	// text/rev/op log) used by writeJot to merge concurrent edits,
+	// guarded by jotsMu. Each jotState has its own mu for the per-token
+	// critical section.
+	jotsMu sync.Mutex
+	jots   map[string]*jotState
 }
 
-// JotAction is used to decode signals from datastar POST requests
-type JotAction struct {
+// WriteRequest is used to decode signals from datastar POST requests.
+// Content alone is a legacy/fallback whole-document write (also what
+// the REST API's PUT uses internally); BaseRev+Ops carry a delta
+// against a specific revision so writeJot can merge concurrent edits
+// instead of one clobbering the other.
+type WriteRequest struct {
 	Content string `json:"content"`
+	BaseRev int    `json:"baseRev"`
+	Ops     []Op   `json:"ops"`
 }
 
 func NewServer() (*Server, error) {
@@ -95,16 +308,38 @@ func NewServer() (*Server, error) {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
+	loginTmpl, err := template.New("login").Parse(loginTemplate)
+	if err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to parse login template: %w", err)
+	}
+
+	sessionSecret, err := generateSessionSecret()
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
 	return &Server{
-		jotDir:     jotDir,
-		host:       host,
-		port:       port,
-		tlsEnabled: tlsEnabled,
-		certFile:   certFile,
-		keyFile:    keyFile,
-		watcher:    watcher,
-		clients:    make(map[string]map[string]chan []byte),
-		tmpl:       tmpl,
+		jotDir:        jotDir,
+		host:          host,
+		port:          port,
+		tlsEnabled:    tlsEnabled,
+		certFile:      certFile,
+		keyFile:       keyFile,
+		watcher:       watcher,
+		clients:       make(map[string]map[string]chan []byte),
+		lastWriter:    make(map[string]string),
+		stuckSince:    make(map[string]map[string]time.Time),
+		slowClients:   make(map[string]map[string]chan struct{}),
+		shutdown:      make(chan struct{}),
+		tmpl:          tmpl,
+		loginTmpl:     loginTmpl,
+		sessionSecret: sessionSecret,
+		revCounter:    make(map[string]int),
+		lastSize:      make(map[string]int),
+		lastSnapshot:  make(map[string]time.Time),
+		jots:          make(map[string]*jotState),
 	}, nil
 }
 
@@ -114,14 +349,31 @@ func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/new", s.handleNew)
+	mux.HandleFunc("/create", s.handleCreate)
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/logout", s.handleLogout)
 	mux.HandleFunc("/write", s.handleWrite)
 	mux.HandleFunc("/updates", s.handleUpdates)
+	mux.HandleFunc("/history", s.handleHistory)
+	mux.HandleFunc("/revision", s.handleRevision)
+	mux.HandleFunc("/revert", s.handleRevert)
 	mux.Handle("/static/", web.StaticHandler())
 
+	mux.HandleFunc("POST /api/v1/jots", s.apiCreateJot)
+	mux.HandleFunc("GET /api/v1/jots/{token}", s.apiAuthMiddleware(s.apiGetJot))
+	mux.HandleFunc("PUT /api/v1/jots/{token}", s.apiAuthMiddleware(s.apiUpdateJot))
+	mux.HandleFunc("DELETE /api/v1/jots/{token}", s.apiAuthMiddleware(s.apiDeleteJot))
+	mux.HandleFunc("GET /api/v1/jots/{token}/watch", s.apiAuthMiddleware(s.apiWatchJot))
+
+	handler, err := compressionMiddleware(mux)
+	if err != nil {
+		return err
+	}
+
 	addr := fmt.Sprintf("%s:%s", s.host, s.port)
 	s.httpServer = &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: handler,
 	}
 
 	if s.tlsEnabled {
@@ -160,7 +412,30 @@ func (s *Server) handleFileChange(filename string) {
 	}
 	token := strings.TrimPrefix(strings.TrimSuffix(base, ".txt"), "jot_")
 
-	content, err := os.ReadFile(filename)
+	// Read content and rev together under st.mu (when a jotState is
+	// tracked), not as two independent reads - every writer that has a
+	// jotState (handleWrite, apiUpdateJot, handleRevert) now does its
+	// disk write under that same st.mu hold, so reading both under the
+	// lock here guarantees this broadcast's content and rev came from
+	// the same write, not content from one write paired with the rev
+	// another, concurrent write already bumped past it.
+	//
+	// rev is jotState's rev, not the durable history-log rev
+	// (currentRev) - they're different counters, and a fresh jotState
+	// always starts at 0 regardless of how many history revisions
+	// already exist, so 0 is the correct value when nothing is tracked
+	// yet, not a fallback to the history log.
+	var content []byte
+	var rev int
+	var err error
+	if st, ok := s.peekJotState(token); ok {
+		st.mu.Lock()
+		content, err = os.ReadFile(filename)
+		rev = st.rev
+		st.mu.Unlock()
+	} else {
+		content, err = os.ReadFile(filename)
+	}
 	if err != nil {
 		log.Printf("Error reading file %s: %v", filename, err)
 		return
@@ -169,6 +444,7 @@ func (s *Server) handleFileChange(filename string) {
 	payload := map[string]any{
 		"datastar-patch-signals": map[string]any{
 			"content": string(content),
+			"rev":     rev,
 		},
 	}
 	messageBytes, err := json.Marshal(payload)
@@ -179,19 +455,52 @@ func (s *Server) handleFileChange(filename string) {
 
 	sseMessage := fmt.Sprintf("data: %s\n\n", messageBytes)
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// Lock (not RLock): broadcastToClients may drop a slow client, which
+	// mutates s.clients and s.stuckSince.
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if clientsForToken, ok := s.clients[token]; ok {
-		s.broadcastToClients(clientsForToken, []byte(sseMessage))
+		s.broadcastToClients(token, clientsForToken, []byte(sseMessage), s.lastWriter[token])
 	}
 }
 
-func (s *Server) broadcastToClients(clients map[string]chan []byte, message []byte) {
-	for _, ch := range clients {
+// slowClientTimeout is how long a client's channel may stay full before
+// broadcastToClients gives up on it and drops it.
+const slowClientTimeout = 2 * time.Second
+
+// broadcastToClients sends message to every client channel except
+// excludeSessionId, which is the session that authored the change (and
+// so already has it applied locally). A client whose channel has been
+// full for more than slowClientTimeout is dropped outright - removed
+// from the broadcast list and signaled via s.slowClients so its
+// handleUpdates goroutine ends the connection - rather than silently
+// skipped forever, which would otherwise permanently desync it.
+func (s *Server) broadcastToClients(token string, clients map[string]chan []byte, message []byte, excludeSessionId string) {
+	now := time.Now()
+	for sessionId, ch := range clients {
+		if excludeSessionId != "" && sessionId == excludeSessionId {
+			continue
+		}
 		select {
 		case ch <- message:
+			delete(s.stuckSince[token], sessionId)
 		default:
-			// Channel is full or closed, skip
+			if s.stuckSince[token] == nil {
+				s.stuckSince[token] = make(map[string]time.Time)
+			}
+			since, ok := s.stuckSince[token][sessionId]
+			if !ok {
+				s.stuckSince[token][sessionId] = now
+				continue
+			}
+			if now.Sub(since) > slowClientTimeout {
+				delete(clients, sessionId)
+				delete(s.stuckSince[token], sessionId)
+				if signal, ok := s.slowClients[token][sessionId]; ok {
+					close(signal)
+					delete(s.slowClients[token], sessionId)
+				}
+			}
 		}
 	}
 }
@@ -231,12 +540,37 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		http.Error(w, "Failed to read jot file", http.StatusInternalServerError)
+	if s.hasCredential(token) && !s.authenticateProtected(r, token) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusUnauthorized)
+		if err := s.loginTmpl.Execute(w, struct{ Token string }{Token: token}); err != nil {
+			http.Error(w, "Failed to render login form", http.StatusInternalServerError)
+		}
 		return
 	}
 
+	// Prefer an already-tracked jotState so the page gets the same rev
+	// the in-memory OT log is tracking - the client's diffing script
+	// needs that as its starting baseRev. Use peekJotState, not
+	// getJotState: merely viewing a jot shouldn't create collaboration
+	// state for it (that map has no eviction, and most views are never
+	// edited), so a token nobody has written to since this process
+	// started just gets rev 0, which is correct for it.
+	var content string
+	var rev int
+	if st, ok := s.peekJotState(token); ok {
+		st.mu.Lock()
+		content, rev = st.text, st.rev
+		st.mu.Unlock()
+	} else {
+		raw, err := os.ReadFile(filename)
+		if err != nil {
+			http.Error(w, "Failed to read jot file", http.StatusInternalServerError)
+			return
+		}
+		content = string(raw)
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    token,
@@ -247,20 +581,14 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html")
 
-	jsonContent, err := json.Marshal(string(content))
-	if err != nil {
-		http.Error(w, "Failed to marshal content", http.StatusInternalServerError)
-		return
-	}
-
 	data := struct {
-		Content     string
-		ContentJSON template.JS
-		Token       string
+		Content string
+		Rev     int
+		Token   string
 	}{
-		Content:     string(content),
-		ContentJSON: template.JS(jsonContent),
-		Token:       token,
+		Content: content,
+		Rev:     rev,
+		Token:   token,
 	}
 
 	if err := s.tmpl.Execute(w, data); err != nil {
@@ -277,63 +605,160 @@ func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request) {
 
 	token, err := s.getValidToken(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeTokenError(w, err)
 		return
 	}
 
-	var action JotAction
+	var action WriteRequest
 	if err := datastar.ReadSignals(r, &action); err != nil {
 		http.Error(w, "Invalid signals", http.StatusBadRequest)
 		return
 	}
 
-	filename := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token))
+	// Tag this write with its originating session so the file watcher
+	// can skip echoing the change back to the client that just sent it.
+	sessionId := r.Header.Get("X-Session-Id")
+	s.mu.Lock()
+	s.lastWriter[token] = sessionId
+	s.mu.Unlock()
 
-	// The file watcher will detect the change and broadcast it.
-	if err := os.WriteFile(filename, []byte(action.Content), 0644); err != nil {
-		http.Error(w, "Failed to write file", http.StatusInternalServerError)
+	st, err := s.getJotState(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
+	filename := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token))
 
-func (s *Server) handleUpdates(w http.ResponseWriter, r *http.Request) {
-	token, err := s.getValidToken(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Hold st.mu across the merge and the disk write together, not just
+	// the merge: two concurrent writes to the same token are ordered by
+	// writeJotLocked under this lock, and a broadcast (handleFileChange)
+	// later pairs disk content with st.rev by reading them separately,
+	// so disk writes must land in the same order the rev counter did or
+	// that broadcast could report a rev that doesn't match the content
+	// it's actually reading.
+	st.mu.Lock()
+	content, rev, mergeErr := s.writeJotLocked(st, action)
+	var writeErr error
+	if mergeErr == nil {
+		writeErr = os.WriteFile(filename, []byte(content), 0644)
+	}
+	st.mu.Unlock()
+	if mergeErr != nil {
+		http.Error(w, mergeErr.Error(), http.StatusConflict)
+		return
+	}
+	if writeErr != nil {
+		http.Error(w, "Failed to write file", http.StatusInternalServerError)
 		return
 	}
 
-	sessionId := uuid.New().String()
+	// The file watcher will also pick up this write and broadcast it to
+	// other sessions.
+	if _, err := s.recordRevision(token, sessionId, []byte(content)); err != nil {
+		log.Printf("Error recording revision for %s: %v", token, err)
+	}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
+	// Let the client's diffing script learn the rev its own write landed
+	// at, so its next debounced edit sends an accurate baseRev. This is
+	// the rev writeJot itself produced, not a re-read of st.rev after
+	// the fact, which a concurrent write from another session could
+	// have since bumped past what this write's content reflects.
+	w.Header().Set("X-Jot-Rev", strconv.Itoa(rev))
 
-	clientChan := make(chan []byte, 10)
+	w.WriteHeader(http.StatusNoContent)
+}
 
+// registerClient adds a new broadcast subscriber for token, enforcing
+// maxClientsPerToken and wiring up the slow-consumer drop signal
+// broadcastToClients uses. Both handleUpdates (SSE) and the REST API's
+// apiWatchJot (NDJSON) share this so they get the same connection cap
+// and slow-consumer handling rather than each registering into
+// s.clients by hand.
+func (s *Server) registerClient(token, sessionId string) (clientChan chan []byte, slowSignal chan struct{}, ok bool) {
+	maxClients := maxClientsPerToken()
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.slowClients == nil {
+		s.slowClients = make(map[string]map[string]chan struct{})
+	}
+	if s.stuckSince == nil {
+		s.stuckSince = make(map[string]map[string]time.Time)
+	}
+	if len(s.clients[token]) >= maxClients {
+		return nil, nil, false
+	}
+
+	clientChan = make(chan []byte, 10)
+	slowSignal = make(chan struct{})
+
 	if s.clients[token] == nil {
 		s.clients[token] = make(map[string]chan []byte)
 	}
 	s.clients[token][sessionId] = clientChan
-	s.mu.Unlock()
+	if s.slowClients[token] == nil {
+		s.slowClients[token] = make(map[string]chan struct{})
+	}
+	s.slowClients[token][sessionId] = slowSignal
 
-	defer func() {
-		s.mu.Lock()
-		if s.clients[token] != nil {
-			delete(s.clients[token], sessionId)
-			if len(s.clients[token]) == 0 {
-				delete(s.clients, token)
-			}
+	return clientChan, slowSignal, true
+}
+
+// unregisterClient removes sessionId's entry from s.clients,
+// s.slowClients, and s.stuckSince for token. Pairs with registerClient.
+func (s *Server) unregisterClient(token, sessionId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[token] != nil {
+		delete(s.clients[token], sessionId)
+		if len(s.clients[token]) == 0 {
+			delete(s.clients, token)
+		}
+	}
+	if s.slowClients[token] != nil {
+		delete(s.slowClients[token], sessionId)
+		if len(s.slowClients[token]) == 0 {
+			delete(s.slowClients, token)
 		}
-		s.mu.Unlock()
+	}
+	delete(s.stuckSince[token], sessionId)
+}
+
+func (s *Server) handleUpdates(w http.ResponseWriter, r *http.Request) {
+	token, err := s.getValidToken(r)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	// The browser's EventSource API can't set custom headers, so the
+	// page's own script passes its session id as a query param instead;
+	// X-Session-Id still wins for any other caller that can set it.
+	sessionId := r.Header.Get("X-Session-Id")
+	if sessionId == "" {
+		sessionId = r.URL.Query().Get("sessionId")
+	}
+	if sessionId == "" {
+		sessionId = uuid.New().String()
+	}
+
+	clientChan, slowSignal, ok := s.registerClient(token, sessionId)
+	if !ok {
+		http.Error(w, "too many connections for this token", http.StatusTooManyRequests)
+		return
+	}
+	defer func() {
+		s.unregisterClient(token, sessionId)
 		close(clientChan)
 	}()
 
-	fmt.Fprintf(w, "event: message\ndata: {\"message\": \"connected\"}\n\n")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	fmt.Fprintf(w, "event: message\ndata: {\"message\": \"connected\", \"sessionId\": %q}\n\n", sessionId)
 	if f, ok := w.(http.Flusher); ok {
 		f.Flush()
 	}
@@ -343,6 +768,18 @@ func (s *Server) handleUpdates(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
+		case <-s.shutdown:
+			fmt.Fprintf(w, "event: bye\ndata: {\"reason\":\"shutdown\"}\n\n")
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			return
+		case <-slowSignal:
+			fmt.Fprintf(w, "event: bye\ndata: {\"reason\":\"slow_consumer\"}\n\n")
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			return
 		case <-r.Context().Done():
 			return
 		case message := <-clientChan:
@@ -361,6 +798,19 @@ func (s *Server) handleUpdates(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxClientsPerToken returns the maximum number of concurrent
+// /updates subscribers allowed for a single token, from
+// JOT_MAX_CLIENTS_PER_TOKEN (default 32). This bounds an otherwise
+// unbounded per-token channel registration.
+func maxClientsPerToken() int {
+	if v := getEnv("JOT_MAX_CLIENTS_PER_TOKEN", ""); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 32
+}
+
 func (s *Server) handleNew(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -457,6 +907,10 @@ func (s *Server) getValidToken(r *http.Request) (string, error) {
 		return "", fmt.Errorf("error checking token: %w", err)
 	}
 
+	if s.hasCredential(token) && !s.authenticateProtected(r, token) {
+		return "", errUnauthorized
+	}
+
 	return token, nil
 }
 
@@ -476,7 +930,7 @@ func (s *Server) getDefaultContent(token string) string {
 	}
 	baseURL := fmt.Sprintf("%s://%s:%s", scheme, s.host, s.port)
 
-	return fmt.Sprintf(`Welcome to jotter!
+	return fmt.Sprintf(`Welcome to Jotter!
 
 Make sure to save the link below, it's the only way to access this jot:
 
@@ -499,7 +953,7 @@ func (s *Server) getDefaultContentWithBackReference(newToken, originalToken stri
 	}
 	baseURL := fmt.Sprintf("%s://%s:%s", scheme, s.host, s.port)
 
-	return fmt.Sprintf(`Welcome to jotter!
+	return fmt.Sprintf(`Welcome to Jotter!
 
 This jot was created from: %s/%s
 
@@ -525,6 +979,13 @@ func getEnv(key, defaultValue string) string {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compact" {
+		if err := runCompact(); err != nil {
+			log.Fatalf("compact failed: %v", err)
+		}
+		return
+	}
+
 	server, err := NewServer()
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
@@ -541,6 +1002,11 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
+	// Tell every in-flight /updates goroutine to say goodbye and return
+	// before we start the HTTP server's own shutdown timeout, so clients
+	// see a clean SSE close instead of an abrupt EOF.
+	close(server.shutdown)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := server.httpServer.Shutdown(ctx); err != nil {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -58,6 +59,101 @@ func TestServer_HandleIndex(t *testing.T) {
 	}
 }
 
+// TestServer_HandleIndexDoesNotCreateJotState guards against a
+// regression where merely viewing a jot (no write involved) created a
+// permanent entry in s.jots - that map is never evicted, so every page
+// view would otherwise leak memory for the life of the process.
+func TestServer_HandleIndexDoesNotCreateJotState(t *testing.T) {
+	tempDir := t.TempDir()
+
+	server := &Server{
+		jotDir:     tempDir,
+		host:       "localhost",
+		port:       "8000",
+		clients:    make(map[string]map[string]chan []byte),
+		lastWriter: make(map[string]string),
+	}
+
+	tmpl, err := template.New("index").Parse(htmlTemplate)
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+	server.tmpl = tmpl
+
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	server.handleIndex(w, req)
+
+	var token string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "token" {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		t.Fatal("expected a token cookie to be set")
+	}
+
+	if _, ok := server.peekJotState(token); ok {
+		t.Error("expected viewing a jot not to create a jotState for it")
+	}
+}
+
+// TestHandleFileChangeRevDefaultsToZeroWithoutJotState guards against a
+// regression where the SSE broadcast's rev fell back to the durable
+// history-log counter (currentRev) when no jotState was tracked yet -
+// that's a different revision space than jotState.rev, so a client
+// seeded with that rev as its baseRev could never satisfy writeJot's
+// baseRev check on its first OT write.
+func TestHandleFileChangeRevDefaultsToZeroWithoutJotState(t *testing.T) {
+	tempDir := t.TempDir()
+	server := &Server{
+		jotDir:     tempDir,
+		clients:    make(map[string]map[string]chan []byte),
+		lastWriter: make(map[string]string),
+	}
+	token := "filechange-token"
+	filename := filepath.Join(tempDir, fmt.Sprintf("jot_%s.txt", token))
+	if err := os.WriteFile(filename, []byte("initial"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+
+	// Bump the history log's rev without creating a jotState, the way
+	// apiUpdateJot does for a token nobody has opened in a browser yet.
+	if _, err := server.recordRevision(token, "api:"+token, []byte("initial")); err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+	if _, err := server.recordRevision(token, "api:"+token, []byte("initial")); err != nil {
+		t.Fatalf("recordRevision failed: %v", err)
+	}
+
+	clientChan, _, ok := server.registerClient(token, "watcher")
+	if !ok {
+		t.Fatal("failed to register watcher")
+	}
+	defer close(clientChan)
+
+	server.handleFileChange(filename)
+
+	select {
+	case message := <-clientChan:
+		var parsed struct {
+			DatastarPatchSignals struct {
+				Rev int `json:"rev"`
+			} `json:"datastar-patch-signals"`
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(string(message), "data: "))
+		if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
+			t.Fatalf("failed to parse broadcast payload %q: %v", payload, err)
+		}
+		if parsed.DatastarPatchSignals.Rev != 0 {
+			t.Errorf("expected broadcast rev 0 (no jotState tracked), got %d (history log is at rev 2)", parsed.DatastarPatchSignals.Rev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for broadcast")
+	}
+}
+
 func TestServer_HandleWrite(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -275,25 +371,35 @@ func TestConnectionCleanup(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	// Simulate multiple connections and disconnections
+	// Simulate multiple connections and disconnections. httptest's
+	// default request context never cancels on its own, so each
+	// connection gets its own cancelable context that we cancel shortly
+	// after starting it, the way a real client's socket close would
+	// cancel r.Context() - otherwise handleUpdates has no way to notice
+	// the "disconnect" and the cleanup this test is stressing never runs.
+	var wg sync.WaitGroup
 	for i := 0; i < 100; i++ {
 		req := httptest.NewRequest("GET", "/updates", nil)
 		req.AddCookie(&http.Cookie{Name: "token", Value: token})
 		req.Header.Set("X-Session-Id", fmt.Sprintf("session-%d", i))
 
+		ctx, cancel := context.WithCancel(req.Context())
+		req = req.WithContext(ctx)
+
 		w := httptest.NewRecorder()
 
-		// Start connection in goroutine
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			server.handleUpdates(w, req)
 		}()
 
 		// Brief wait then "disconnect"
 		time.Sleep(1 * time.Millisecond)
+		cancel()
 	}
 
-	// Wait longer for cleanup to allow timeouts to trigger
-	time.Sleep(100 * time.Millisecond)
+	wg.Wait()
 
 	// Check that connections were cleaned up
 	server.mu.RLock()
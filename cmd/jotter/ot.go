@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Op is a single text edit: retain leaves the first Retain runes of the
+// document untouched, Delete removes the next Delete runes, and Insert
+// is spliced in at the resulting cursor position. This is the
+// retain/delete/insert delta shape the textarea client sends for one
+// edit region (one contiguous typed/pasted/deleted run), not a general
+// multi-hunk patch.
+type Op struct {
+	Retain int    `json:"retain"`
+	Delete int    `json:"delete"`
+	Insert string `json:"insert"`
+}
+
+// opEntry is an Op together with the revision it produced, so a
+// late-arriving write can be transformed against everything that
+// landed since its baseRev.
+type opEntry struct {
+	Rev int
+	Op  Op
+}
+
+// jotState is the authoritative in-memory state for one token's live
+// document: the current revision, its text, and a short log of recent
+// ops to transform against. All access goes through mu, so concurrent
+// handleWrite/apiUpdateJot calls for the same token never interleave.
+// This is intentionally in-memory only, like s.clients and
+// s.lastWriter: a restart resets collaboration state to whatever is on
+// disk, which is an acceptable loss of recent-edit-merging (not of
+// data) for a process restart.
+type jotState struct {
+	mu   sync.Mutex
+	rev  int
+	text string
+	log  []opEntry
+}
+
+// otLogLimit bounds how many recent ops a jotState keeps for
+// transforming against. A write whose baseRev is older than the oldest
+// entry still in the log is rejected rather than silently replayed
+// against ops that are no longer tracked.
+const otLogLimit = 200
+
+// getJotState returns (creating and seeding from disk if necessary)
+// the live jotState for token.
+func (s *Server) getJotState(token string) (*jotState, error) {
+	s.jotsMu.Lock()
+	defer s.jotsMu.Unlock()
+
+	if s.jots == nil {
+		s.jots = make(map[string]*jotState)
+	}
+	if st, ok := s.jots[token]; ok {
+		return st, nil
+	}
+
+	filename := filepath.Join(s.jotDir, fmt.Sprintf("jot_%s.txt", token))
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seed jot state: %w", err)
+	}
+	st := &jotState{text: string(content)}
+	s.jots[token] = st
+	return st, nil
+}
+
+// peekJotState returns token's jotState without creating one, so
+// callers that write to the file outside writeJot (e.g. handleRevert)
+// can keep an already-tracked jotState in sync without needlessly
+// seeding state for tokens nobody is collaborating on.
+func (s *Server) peekJotState(token string) (*jotState, bool) {
+	s.jotsMu.Lock()
+	defer s.jotsMu.Unlock()
+	st, ok := s.jots[token]
+	return st, ok
+}
+
+// applyOps applies ops, in order, to text and returns the result.
+func applyOps(text string, ops []Op) (string, error) {
+	r := []rune(text)
+	var out []rune
+	pos := 0
+	for _, op := range ops {
+		if op.Retain < 0 || op.Delete < 0 {
+			return "", fmt.Errorf("retain/delete must be non-negative")
+		}
+		if pos+op.Retain > len(r) {
+			return "", fmt.Errorf("retain extends past end of document")
+		}
+		out = append(out, r[pos:pos+op.Retain]...)
+		pos += op.Retain
+		if pos+op.Delete > len(r) {
+			return "", fmt.Errorf("delete extends past end of document")
+		}
+		pos += op.Delete
+		out = append(out, []rune(op.Insert)...)
+	}
+	out = append(out, r[pos:]...)
+	return string(out), nil
+}
+
+// replaceOp returns the Op that replaces all of oldText with newText,
+// used to record whole-document writes (the legacy/fallback path, and
+// external writers like handleRevert) in the same op log as real
+// deltas, so they still participate correctly in future transforms.
+func replaceOp(oldText, newText string) Op {
+	return Op{Retain: 0, Delete: len([]rune(oldText)), Insert: newText}
+}
+
+// transformOp adjusts op so it applies cleanly to a document that
+// against has already been applied to, per the standard
+// operational-transform approach: shift op's region to account for
+// against's effect on the document length, and clip any overlap in
+// against's favor, since against was applied first. This handles a
+// single contiguous edit region per Op (which is what one retain/
+// delete/insert delta represents); it does not attempt to transform
+// arbitrary multi-hunk patches against each other.
+func transformOp(op, against Op) Op {
+	opStart, opEnd := op.Retain, op.Retain+op.Delete
+	againstStart, againstEnd := against.Retain, against.Retain+against.Delete
+	shift := len([]rune(against.Insert)) - against.Delete
+
+	switch {
+	case opStart >= againstEnd:
+		// op's region is entirely after against's: shift it by
+		// however much against's edit changed the document length.
+		op.Retain += shift
+	case opEnd <= againstStart:
+		// op's region is entirely before against's: unaffected.
+	case opStart < againstStart:
+		// The regions overlap and op starts first: keep the part of
+		// op's delete that falls before against's edit and drop the
+		// rest, since against already removed/replaced it.
+		op.Delete = againstStart - opStart
+	default:
+		// op starts inside against's deleted region: move op past
+		// against's insert and drop whatever part of op's delete
+		// against already consumed.
+		consumed := against.Delete - (opStart - againstStart)
+		if consumed > op.Delete {
+			consumed = op.Delete
+		}
+		op.Retain = againstStart + len([]rune(against.Insert))
+		op.Delete -= consumed
+		if op.Delete < 0 {
+			op.Delete = 0
+		}
+	}
+	return op
+}
+
+// appendLog appends entry to st.log, trimming it back to otLogLimit
+// from the tail. Callers must hold st.mu.
+func (st *jotState) appendLog(rev int, op Op) {
+	st.log = append(st.log, opEntry{Rev: rev, Op: op})
+	if len(st.log) > otLogLimit {
+		st.log = st.log[len(st.log)-otLogLimit:]
+	}
+}
+
+// writeJot applies action to token's live document and returns the
+// resulting full text and the revision it landed at. The rev is
+// returned from under the same st.mu hold that produced it, since a
+// caller that re-reads st.rev after unlocking could observe a later
+// write's rev instead of its own. See writeJotLocked for the merge
+// logic itself.
+func (s *Server) writeJot(token string, action WriteRequest) (string, int, error) {
+	st, err := s.getJotState(token)
+	if err != nil {
+		return "", 0, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return s.writeJotLocked(st, action)
+}
+
+// writeJotLocked is writeJot's merge logic, split out so handleWrite can
+// hold st.mu across both the in-memory merge and the resulting disk
+// write - otherwise two concurrent writers to the same token could bump
+// st.rev in one order but land their os.WriteFile calls in the other,
+// leaving disk content from an earlier rev paired with a later rev
+// number in the next broadcast. Callers must already hold st.mu.
+//
+// If action carries Ops, they're transformed against any writes
+// recorded since action.BaseRev and merged in, rather than the whole
+// document being overwritten - this is what lets two sessions editing
+// concurrently combine their changes instead of one clobbering the
+// other. If action carries no Ops (the legacy whole-content path, still
+// used by any client that just POSTs the full textarea value), the
+// write is recorded as a full replace.
+func (s *Server) writeJotLocked(st *jotState, action WriteRequest) (string, int, error) {
+	if len(action.Ops) == 0 {
+		op := replaceOp(st.text, action.Content)
+		st.rev++
+		st.appendLog(st.rev, op)
+		st.text = action.Content
+		return st.text, st.rev, nil
+	}
+
+	if action.BaseRev < 0 || action.BaseRev > st.rev {
+		return "", 0, fmt.Errorf("invalid baseRev %d (current rev %d)", action.BaseRev, st.rev)
+	}
+	if len(st.log) > 0 && action.BaseRev < st.log[0].Rev-1 {
+		return "", 0, fmt.Errorf("baseRev %d is too far behind current rev %d to transform", action.BaseRev, st.rev)
+	}
+
+	ops := append([]Op(nil), action.Ops...)
+	for _, entry := range st.log {
+		if entry.Rev <= action.BaseRev {
+			continue
+		}
+		for i := range ops {
+			ops[i] = transformOp(ops[i], entry.Op)
+		}
+	}
+
+	newText, err := applyOps(st.text, ops)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to apply ops: %w", err)
+	}
+
+	st.rev++
+	for _, op := range ops {
+		st.appendLog(st.rev, op)
+	}
+	st.text = newText
+	return st.text, st.rev, nil
+}
+
+// syncExternalWriteLocked updates st to reflect content written outside
+// writeJot. Callers must already hold st.mu - see applyExternalWrite,
+// which is how every production caller (handleRevert, apiUpdateJot)
+// reaches this.
+func (s *Server) syncExternalWriteLocked(st *jotState, content string) {
+	op := replaceOp(st.text, content)
+	st.rev++
+	st.appendLog(st.rev, op)
+	st.text = content
+}
+
+// applyExternalWrite runs write (the actual disk I/O - an os.WriteFile
+// or os.Rename) and, if it succeeds and token already has a tracked
+// jotState, syncs that state to content - both under the same st.mu
+// hold. Used by every writer that modifies the jot file directly rather
+// than through writeJot (handleRevert, apiUpdateJot), so
+// handleFileChange's own locked content+rev read can never observe this
+// write's new file content paired with the pre-write rev, or vice versa.
+// If no jotState is tracked yet, write just runs unlocked: there's
+// nothing else reading that token's (nonexistent) jotState to race with.
+func (s *Server) applyExternalWrite(token, content string, write func() error) error {
+	st, ok := s.peekJotState(token)
+	if !ok {
+		return write()
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := write(); err != nil {
+		return err
+	}
+	s.syncExternalWriteLocked(st, content)
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyOps(t *testing.T) {
+	got, err := applyOps("hello world", []Op{{Retain: 6, Delete: 5, Insert: "there"}})
+	if err != nil {
+		t.Fatalf("applyOps failed: %v", err)
+	}
+	if got != "hello there" {
+		t.Errorf("expected %q, got %q", "hello there", got)
+	}
+}
+
+func TestApplyOpsRejectsOutOfRange(t *testing.T) {
+	if _, err := applyOps("short", []Op{{Retain: 100}}); err == nil {
+		t.Error("expected an error for retain past end of document")
+	}
+}
+
+// TestWriteJotMergesConcurrentEdits simulates two sessions both
+// starting from the same base revision and editing disjoint regions of
+// the document: neither edit should clobber the other.
+func TestWriteJotMergesConcurrentEdits(t *testing.T) {
+	tempDir := t.TempDir()
+	token := "merge-token"
+	if err := os.WriteFile(filepath.Join(tempDir, "jot_"+token+".txt"), []byte("abcdefghij"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	server := &Server{jotDir: tempDir}
+
+	// Session A inserts "X" right after "abc" (rev 0 -> 1).
+	resultA, revA, err := server.writeJot(token, WriteRequest{
+		BaseRev: 0,
+		Ops:     []Op{{Retain: 3, Insert: "X"}},
+	})
+	if err != nil {
+		t.Fatalf("session A write failed: %v", err)
+	}
+	if resultA != "abcXdefghij" {
+		t.Fatalf("unexpected text after session A: %q", resultA)
+	}
+	if revA != 1 {
+		t.Errorf("expected session A's write to land at rev 1, got %d", revA)
+	}
+
+	// Session B, unaware of A's edit, inserts "Y" after "ghi" based on
+	// the original (rev 0) document.
+	resultB, revB, err := server.writeJot(token, WriteRequest{
+		BaseRev: 0,
+		Ops:     []Op{{Retain: 9, Insert: "Y"}},
+	})
+	if err != nil {
+		t.Fatalf("session B write failed: %v", err)
+	}
+
+	want := "abcXdefghiYj"
+	if resultB != want {
+		t.Errorf("expected transformed merge %q, got %q", want, resultB)
+	}
+	if revB != 2 {
+		t.Errorf("expected session B's write to land at rev 2, got %d", revB)
+	}
+}
+
+func TestWriteJotRejectsStaleBaseRev(t *testing.T) {
+	tempDir := t.TempDir()
+	token := "stale-token"
+	if err := os.WriteFile(filepath.Join(tempDir, "jot_"+token+".txt"), []byte("abc"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	server := &Server{jotDir: tempDir}
+
+	if _, _, err := server.writeJot(token, WriteRequest{BaseRev: 5, Ops: []Op{{Retain: 1}}}); err == nil {
+		t.Error("expected an error for a baseRev ahead of the current revision")
+	}
+}
+
+func TestWriteJotLegacyWholeContentWrite(t *testing.T) {
+	tempDir := t.TempDir()
+	token := "legacy-token"
+	if err := os.WriteFile(filepath.Join(tempDir, "jot_"+token+".txt"), []byte("initial"), 0644); err != nil {
+		t.Fatalf("failed to seed file: %v", err)
+	}
+	server := &Server{jotDir: tempDir}
+
+	got, rev, err := server.writeJot(token, WriteRequest{Content: "replaced"})
+	if err != nil {
+		t.Fatalf("legacy write failed: %v", err)
+	}
+	if got != "replaced" {
+		t.Errorf("expected %q, got %q", "replaced", got)
+	}
+	if rev != 1 {
+		t.Errorf("expected rev 1, got %d", rev)
+	}
+}